@@ -0,0 +1,235 @@
+package integration
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiserverserviceaccount "k8s.io/apiserver/pkg/authentication/serviceaccount"
+	restclient "k8s.io/client-go/rest"
+
+	"github.com/openshift/oauth-server/pkg/scopecovers"
+	testserver "github.com/openshift/origin/test/util/server"
+)
+
+// openIDConfiguration is the subset of the OIDC discovery document this test cares about.
+type openIDConfiguration struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	ScopesSupported       []string `json:"scopes_supported"`
+}
+
+// jsonWebKeySet is the subset of RFC 7517 this test needs to reconstruct an RSA public key.
+type jsonWebKeySet struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// TestOAuthServiceAccountClientOIDC verifies that an SA client requesting the "openid" scope
+// gets back a signed id_token from /oauth/token, and that the token verifies against the keys
+// published at the OIDC discovery document's jwks_uri.
+func TestOAuthServiceAccountClientOIDC(t *testing.T) {
+	testServer, err := setupTestOAuthServer()
+	if err != nil {
+		t.Fatalf("error setting up test server: %s", err)
+	}
+	defer testServer.oauthServer.Close()
+	defer testserver.CleanupMasterEtcd(t, testServer.masterConfig)
+
+	redirect := testServer.oauthServer.URL + "/oauthcallback"
+	sa, err := setupTestSA(testServer.clusterAdminKubeClient, "serviceaccounts.openshift.io/oauth-redirecturi.one", redirect)
+	if err != nil {
+		t.Fatalf("error setting up test SA: %s", err)
+	}
+	secret, err := setupTestSecrets(testServer.clusterAdminKubeClient, sa)
+	if err != nil {
+		t.Fatalf("error setting up test secrets: %s", err)
+	}
+
+	discoveryResp, err := http.Get(testServer.clusterAdminClientConfig.Host + "/.well-known/openid-configuration")
+	if err != nil {
+		t.Fatalf("error fetching discovery document: %v", err)
+	}
+	defer discoveryResp.Body.Close()
+	if discoveryResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from discovery endpoint, got %v", discoveryResp.StatusCode)
+	}
+	var discovery openIDConfiguration
+	if err := json.NewDecoder(discoveryResp.Body).Decode(&discovery); err != nil {
+		t.Fatalf("error decoding discovery document: %v", err)
+	}
+	if discovery.Issuer != testServer.clusterAdminClientConfig.Host {
+		t.Errorf("expected issuer %s, got %s", testServer.clusterAdminClientConfig.Host, discovery.Issuer)
+	}
+
+	jwksResp, err := http.Get(discovery.JWKSURI)
+	if err != nil {
+		t.Fatalf("error fetching jwks: %v", err)
+	}
+	defer jwksResp.Body.Close()
+	var jwks jsonWebKeySet
+	if err := json.NewDecoder(jwksResp.Body).Decode(&jwks); err != nil {
+		t.Fatalf("error decoding jwks: %v", err)
+	}
+	if len(jwks.Keys) == 0 {
+		t.Fatalf("expected at least one signing key to be published")
+	}
+
+	code := oidcAuthorizeForCode(t, testServer, sa, redirect)
+
+	tokenURL := testServer.clusterAdminClientConfig.Host + "/oauth/token"
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirect)
+	form.Set("client_id", apiserverserviceaccount.MakeUsername(sa.Namespace, sa.Name))
+	form.Set("client_secret", string(secret.Data[corev1.ServiceAccountTokenKey]))
+
+	tokenResp, err := http.PostForm(tokenURL, form)
+	if err != nil {
+		t.Fatalf("error exchanging code for a token: %v", err)
+	}
+	defer tokenResp.Body.Close()
+	if tokenResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from token endpoint, got %v", tokenResp.StatusCode)
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := json.NewDecoder(tokenResp.Body).Decode(&tokenResponse); err != nil {
+		t.Fatalf("error decoding token response: %v", err)
+	}
+	if len(tokenResponse.IDToken) == 0 {
+		t.Fatalf("expected an id_token in the response when scope included openid")
+	}
+
+	claims := verifyIDToken(t, tokenResponse.IDToken, jwks)
+
+	if claims["iss"] != testServer.clusterAdminClientConfig.Host {
+		t.Errorf("expected iss %s, got %v", testServer.clusterAdminClientConfig.Host, claims["iss"])
+	}
+	wantAud := apiserverserviceaccount.MakeUsername(sa.Namespace, sa.Name)
+	if claims["aud"] != wantAud {
+		t.Errorf("expected aud %s, got %v", wantAud, claims["aud"])
+	}
+	if _, ok := claims["sub"]; !ok {
+		t.Errorf("expected a sub claim")
+	}
+}
+
+// oidcAuthorizeForCode drives the authorize/approve HTML flow (via the shared doOAuthAuthorize
+// helper) with scope=openid and returns the resulting authorization code.
+func oidcAuthorizeForCode(t *testing.T, ts *testServer, sa *corev1.ServiceAccount, redirectURL string) string {
+	authorizeURL, err := url.Parse(ts.clusterAdminClientConfig.Host + "/oauth/authorize")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q := authorizeURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", apiserverserviceaccount.MakeUsername(sa.Namespace, sa.Name))
+	q.Set("redirect_uri", redirectURL)
+	q.Set("scope", scopecovers.Join([]string{"user:info", "role:edit:" + projectName, "openid"}))
+	authorizeURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", authorizeURL.String(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clientTransport, err := restclient.TransportFor(ts.clusterAdminClientConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	code := doOAuthAuthorize(t, clientTransport, req, ts.authCodes, ts.authErrors, false, nil)
+	if len(code) == 0 {
+		t.Fatalf("expected an authorization code but got none")
+	}
+	return code
+}
+
+// verifyIDToken parses a compact JWT, locates its signing key by "kid" in the given key set,
+// verifies the RS256 signature, and returns the decoded claims.
+func verifyIDToken(t *testing.T, idToken string, jwks jsonWebKeySet) map[string]interface{} {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected a compact JWT with 3 segments, got %d", len(parts))
+	}
+
+	headerBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		t.Fatalf("error decoding JWT header: %v", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		t.Fatalf("error unmarshalling JWT header: %v", err)
+	}
+
+	var pubKey *rsa.PublicKey
+	for _, key := range jwks.Keys {
+		if key.Kid != header.Kid {
+			continue
+		}
+		nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			t.Fatalf("error decoding jwk n: %v", err)
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			t.Fatalf("error decoding jwk e: %v", err)
+		}
+		pubKey = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nBytes),
+			E: int(new(big.Int).SetBytes(eBytes).Int64()),
+		}
+	}
+	if pubKey == nil {
+		t.Fatalf("no published key matched id_token kid %q", header.Kid)
+	}
+
+	signedData := parts[0] + "." + parts[1]
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("error decoding JWT signature: %v", err)
+	}
+	hashed := sha256.Sum256([]byte(signedData))
+	if err := rsa.VerifyPKCS1v15(pubKey, crypto.SHA256, hashed[:], sig); err != nil {
+		t.Fatalf("id_token signature verification failed: %v", err)
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("error decoding JWT payload: %v", err)
+	}
+	var claims map[string]interface{}
+	if err := json.Unmarshal(payloadBytes, &claims); err != nil {
+		t.Fatalf("error unmarshalling JWT claims: %v", err)
+	}
+
+	if exp, ok := claims["exp"].(float64); ok && int64(exp) < time.Now().Unix() {
+		t.Errorf("id_token is already expired")
+	}
+
+	return claims
+}
@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"net/http/httputil"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/RangelReale/osincli"
 
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -50,78 +52,175 @@ var (
 	projectName = "test-project"
 )
 
+// expectedEvent describes a single per-annotation (or terminal) event that
+// TestOAuthServiceAccountClientEvent expects to find after a reconcile.
+type expectedEvent struct {
+	reason    string
+	message   string
+	fieldPath string
+}
+
+const noRedirectURIsMsg = "system:serviceaccount:" + projectName + ":" + saName + " has no redirectURIs; set serviceaccounts.openshift.io/oauth-redirecturi.<some-value>=<redirect> or create a dynamic URI using serviceaccounts.openshift.io/oauth-redirectreference.<some-value>=<reference>"
+
 // TestOAuthServiceAccountClientEvent verifies that certain warning events are created when an SA is incorrectly configured
-// for OAuth
+// for OAuth. Each bad annotation gets its own per-key event (reason from a small enum, fieldPath
+// pointing at the offending annotation), and a single terminal NoSAOAuthRedirectURIs event is
+// emitted only when zero valid redirect URIs remain once all annotations are considered.
 func TestOAuthServiceAccountClientEvent(t *testing.T) {
 
 	tests := map[string]struct {
-		annotationPrefix    string
-		annotation          string
-		expectedEventReason string
-		expectedEventMsg    string
-		numEvents           int
-		expectBadRequest    bool
+		annotationPrefix string
+		annotation       string
+		// annotations, when set, overrides annotationPrefix/annotation and drives a
+		// multi-annotation SA so that multiple per-key events can be exercised at once.
+		annotations      map[string]string
+		expectedEvents   []expectedEvent
+		expectBadRequest bool
+		ingress          *networkingv1.Ingress
+		service          *corev1.Service
+		// includeCallbackURI, when set, also annotates the SA with a literal
+		// oauth-redirecturi.* entry pointing at the test server's own callback, since the
+		// Ingress/Service reference under test resolves to a host the httptest client can't
+		// actually dial. Both annotations are exercised: the reference proves the resolver
+		// works, the literal URI is what the flow below redirects against.
+		includeCallbackURI bool
+		// expectedResolvedURIs, when set, is asserted directly against
+		// oauthserviceaccountclient.ResolveRedirectURIs for the reference annotation under
+		// test - proof the Ingress/Service resolver itself produced these URIs, independent of
+		// the separately-injected includeCallbackURI literal the HTTP flow below redirects against.
+		expectedResolvedURIs []string
 	}{
 		"test-good-url": {
 			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix + "one",
 			annotation:       "/oauthcallback",
-			numEvents:        0,
 		},
 		"test-bad-url": {
-			annotationPrefix:    oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix + "one",
-			annotation:          "foo:foo",
-			expectedEventReason: "NoSAOAuthRedirectURIs",
-			expectedEventMsg:    "system:serviceaccount:" + projectName + ":" + saName + " has no redirectURIs; set serviceaccounts.openshift.io/oauth-redirecturi.<some-value>=<redirect> or create a dynamic URI using serviceaccounts.openshift.io/oauth-redirectreference.<some-value>=<reference>",
-			numEvents:           1,
-			expectBadRequest:    true,
+			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix + "one",
+			annotation:       "foo:foo",
+			expectedEvents: []expectedEvent{
+				{reason: "BadRedirectURI", message: `"foo:foo" is not a valid redirect URI`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix + `one]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
 		},
 		"test-bad-url-parse": {
-			annotationPrefix:    oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix + "one",
-			annotation:          "::",
-			expectedEventReason: "NoSAOAuthRedirectURIs",
-			expectedEventMsg:    "[parse ::: missing protocol scheme, system:serviceaccount:" + projectName + ":" + saName + " has no redirectURIs; set serviceaccounts.openshift.io/oauth-redirecturi.<some-value>=<redirect> or create a dynamic URI using serviceaccounts.openshift.io/oauth-redirectreference.<some-value>=<reference>]",
-			numEvents:           1,
-			expectBadRequest:    true,
+			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix + "one",
+			annotation:       "::",
+			expectedEvents: []expectedEvent{
+				{reason: "BadRedirectURI", message: `parse ::: missing protocol scheme`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix + `one]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
 		},
 		"test-bad-redirect-annotation-kind": {
-			annotationPrefix:    oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
-			annotation:          `{"kind":"foo","apiVersion":"oauth.openshift.io/v1","metadata":{"creationTimestamp":null},"reference":{"group":"foo","kind":"Route","name":"route1"}}`,
-			expectedEventReason: "NoSAOAuthRedirectURIs",
-			expectedEventMsg:    `[no kind "foo" is registered for version "oauth.openshift.io/v1" in scheme "github.com/openshift/library-go/pkg/oauth/oauthserviceaccountclient/oauthclientregistry.go:54", system:serviceaccount:` + projectName + ":" + saName + " has no redirectURIs; set serviceaccounts.openshift.io/oauth-redirecturi.<some-value>=<redirect> or create a dynamic URI using serviceaccounts.openshift.io/oauth-redirectreference.<some-value>=<reference>]",
-			numEvents:           1,
-			expectBadRequest:    true,
+			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
+			annotation:       `{"kind":"foo","apiVersion":"oauth.openshift.io/v1","metadata":{"creationTimestamp":null},"reference":{"group":"foo","kind":"Route","name":"route1"}}`,
+			expectedEvents: []expectedEvent{
+				// The scheme's own source location is stripped from this error (see
+				// stripSchemeLocation) so this assertion doesn't break on incidental
+				// reformatting of oauthclientregistry.go.
+				{reason: "BadRedirectReferenceJSON", message: `no kind "foo" is registered for version "oauth.openshift.io/v1"`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + `1]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
 		},
 		"test-bad-redirect-type-parse": {
-			annotationPrefix:    oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
-			annotation:          `{asdf":"adsf"}`,
-			expectedEventReason: "NoSAOAuthRedirectURIs",
-			expectedEventMsg:    `[couldn't get version/kind; json parse error: invalid character 'a' looking for beginning of object key string, system:serviceaccount:` + projectName + ":" + saName + " has no redirectURIs; set serviceaccounts.openshift.io/oauth-redirecturi.<some-value>=<redirect> or create a dynamic URI using serviceaccounts.openshift.io/oauth-redirectreference.<some-value>=<reference>]",
-			numEvents:           1,
-			expectBadRequest:    true,
+			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
+			annotation:       `{asdf":"adsf"}`,
+			expectedEvents: []expectedEvent{
+				{reason: "BadRedirectReferenceJSON", message: `couldn't get version/kind; json parse error: invalid character 'a' looking for beginning of object key string`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + `1]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
 		},
 		"test-bad-redirect-route-not-found": {
-			annotationPrefix:    oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
-			annotation:          buildRedirectObjectReferenceString(t, "Route", "route1", "route.openshift.io"),
-			expectedEventReason: "NoSAOAuthRedirectURIs",
-			expectedEventMsg:    `[routes.route.openshift.io "route1" not found, system:serviceaccount:` + projectName + ":" + saName + " has no redirectURIs; set serviceaccounts.openshift.io/oauth-redirecturi.<some-value>=<redirect> or create a dynamic URI using serviceaccounts.openshift.io/oauth-redirectreference.<some-value>=<reference>]",
-			numEvents:           1,
-			expectBadRequest:    true,
+			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
+			annotation:       buildRedirectObjectReferenceString(t, "Route", "route1", "route.openshift.io"),
+			expectedEvents: []expectedEvent{
+				{reason: "RedirectTargetNotFound", message: `routes.route.openshift.io "route1" not found`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + `1]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
 		},
 		"test-bad-redirect-route-wrong-group": {
-			annotationPrefix:    oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
-			annotation:          buildRedirectObjectReferenceString(t, "Route", "route1", "foo"),
-			expectedEventReason: "NoSAOAuthRedirectURIs",
-			expectedEventMsg:    `system:serviceaccount:` + projectName + ":" + saName + " has no redirectURIs; set serviceaccounts.openshift.io/oauth-redirecturi.<some-value>=<redirect> or create a dynamic URI using serviceaccounts.openshift.io/oauth-redirectreference.<some-value>=<reference>",
-			numEvents:           1,
-			expectBadRequest:    true,
+			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
+			annotation:       buildRedirectObjectReferenceString(t, "Route", "route1", "foo"),
+			expectedEvents: []expectedEvent{
+				{reason: "RedirectTargetWrongGroup", message: `"foo" is not a valid group for a Route redirect target`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + `1]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
 		},
 		"test-bad-redirect-reference-kind": {
-			annotationPrefix:    oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
-			annotation:          buildRedirectObjectReferenceString(t, "foo", "route1", "route.openshift.io"),
-			expectedEventReason: "NoSAOAuthRedirectURIs",
-			expectedEventMsg:    `system:serviceaccount:` + projectName + ":" + saName + " has no redirectURIs; set serviceaccounts.openshift.io/oauth-redirecturi.<some-value>=<redirect> or create a dynamic URI using serviceaccounts.openshift.io/oauth-redirectreference.<some-value>=<reference>",
-			numEvents:           1,
-			expectBadRequest:    true,
+			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
+			annotation:       buildRedirectObjectReferenceString(t, "foo", "route1", "route.openshift.io"),
+			expectedEvents: []expectedEvent{
+				{reason: "BadRedirectReferenceKind", message: `"foo" is not a supported redirect reference kind`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + `1]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
+		},
+		"test-bad-redirect-ingress-not-found": {
+			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
+			annotation:       buildRedirectObjectReferenceString(t, "Ingress", "ingress1", "networking.k8s.io"),
+			expectedEvents: []expectedEvent{
+				{reason: "RedirectTargetNotFound", message: `ingresses.networking.k8s.io "ingress1" not found`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + `1]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
+		},
+		"test-bad-redirect-ingress-wrong-group": {
+			annotationPrefix: oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
+			annotation:       buildRedirectObjectReferenceString(t, "Ingress", "ingress1", "foo"),
+			expectedEvents: []expectedEvent{
+				{reason: "RedirectTargetWrongGroup", message: `"foo" is not a valid group for an Ingress redirect target`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + `1]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
+		},
+		"test-multi-bad-annotations": {
+			annotations: map[string]string{
+				oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix + "one":       "foo:foo",
+				oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "one": buildRedirectObjectReferenceString(t, "Route", "route1", "route.openshift.io"),
+				oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "two": buildRedirectObjectReferenceString(t, "foo", "route1", "route.openshift.io"),
+			},
+			expectedEvents: []expectedEvent{
+				{reason: "BadRedirectURI", message: `"foo:foo" is not a valid redirect URI`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix + `one]`},
+				{reason: "RedirectTargetNotFound", message: `routes.route.openshift.io "route1" not found`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + `one]`},
+				{reason: "BadRedirectReferenceKind", message: `"foo" is not a supported redirect reference kind`, fieldPath: `metadata.annotations[` + oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + `two]`},
+				{reason: "NoSAOAuthRedirectURIs", message: noRedirectURIsMsg},
+			},
+			expectBadRequest: true,
+		},
+		"test-good-ingress-multi-host": {
+			annotationPrefix:   oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
+			annotation:         buildRedirectObjectReferenceString(t, "Ingress", "ingress1", "networking.k8s.io"),
+			includeCallbackURI: true,
+			ingress: &networkingv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "ingress1"},
+				Spec: networkingv1.IngressSpec{
+					Rules: []networkingv1.IngressRule{
+						{Host: "ingress1.example.com", IngressRuleValue: singlePathIngressRule("/oauthcallback")},
+						{Host: "ingress1-alt.example.com", IngressRuleValue: singlePathIngressRule("/oauthcallback")},
+					},
+				},
+			},
+			expectedResolvedURIs: []string{
+				"http://ingress1.example.com/oauthcallback",
+				"http://ingress1-alt.example.com/oauthcallback",
+			},
+		},
+		"test-good-service": {
+			annotationPrefix:   oauthserviceaccountclient.OAuthRedirectModelAnnotationReferencePrefix + "1",
+			annotation:         buildRedirectObjectReferenceString(t, "Service", "svc1", ""),
+			includeCallbackURI: true,
+			service: &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "svc1"},
+				Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 443}}},
+			},
+			expectedResolvedURIs: []string{
+				"https://svc1." + projectName + ".svc",
+			},
 		},
 	}
 
@@ -134,13 +233,32 @@ func TestOAuthServiceAccountClientEvent(t *testing.T) {
 	defer testserver.CleanupMasterEtcd(t, testServer.masterConfig)
 
 	for tcName, testCase := range tests {
+		annotations := testCase.annotations
+		if annotations == nil {
+			annotations = map[string]string{testCase.annotationPrefix: testCase.annotation}
+		}
+		if testCase.includeCallbackURI {
+			annotations[oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix+"callback"] = testServer.oauthServer.URL + "/oauthcallback"
+		}
+
 		var redirect string = testServer.oauthServer.URL + "/oauthcallback"
-		if testCase.numEvents != 0 {
+		if testCase.expectBadRequest && testCase.annotations == nil {
 			redirect = testCase.annotation
 		}
 
-		t.Logf("%s: annotationPrefix %s, annotation %s", tcName, testCase.annotationPrefix, testCase.annotation)
-		sa, err := setupTestSA(testServer.clusterAdminKubeClient, testCase.annotationPrefix, redirect)
+		if testCase.ingress != nil {
+			if _, err := testServer.clusterAdminKubeClient.NetworkingV1().Ingresses(projectName).Create(testCase.ingress); err != nil {
+				t.Fatalf("%s: error creating test ingress: %s", tcName, err)
+			}
+		}
+		if testCase.service != nil {
+			if _, err := testServer.clusterAdminKubeClient.CoreV1().Services(projectName).Create(testCase.service); err != nil {
+				t.Fatalf("%s: error creating test service: %s", tcName, err)
+			}
+		}
+
+		t.Logf("%s: annotations %v", tcName, annotations)
+		sa, err := setupServiceAccountAnnotations(testServer.clusterAdminKubeClient, annotations)
 		if err != nil {
 			t.Fatalf("%s: error setting up test SA: %s", tcName, err)
 		}
@@ -159,7 +277,7 @@ func TestOAuthServiceAccountClientEvent(t *testing.T) {
 			if err != nil {
 				return false, err
 			}
-			if len(evList.Items) < testCase.numEvents {
+			if len(evList.Items) < len(testCase.expectedEvents) {
 				return false, nil
 			}
 			return true, nil
@@ -169,20 +287,111 @@ func TestOAuthServiceAccountClientEvent(t *testing.T) {
 			t.Fatalf("%s: err polling for events", tcName)
 		}
 
-		events := collectEventsWithReason(evList, testCase.expectedEventReason)
+		assertExpectedEvents(t, tcName, evList, testCase.expectedEvents)
 
-		if testCase.numEvents != len(events) {
-			t.Fatalf("%s: expected %d events, found %d", tcName, testCase.numEvents, len(events))
+		if testCase.expectedResolvedURIs != nil {
+			assertResolvedRedirectURIs(t, tcName, testServer.clusterAdminKubeClient, sa, testCase.expectedResolvedURIs)
 		}
 
-		if testCase.numEvents != 0 && events[0].Message != testCase.expectedEventMsg {
-			t.Fatalf("%s: expected event message %s, got %s", tcName, testCase.expectedEventMsg, events[0].Message)
+		if testCase.expectBadRequest {
+			// Reconciling the same broken SA again must not create a second round of
+			// per-annotation events; ResolveRedirectURIsAndRecordEvents suppresses identical
+			// events for an unchanged SA instead of spamming etcd on every resync.
+			runTestOAuthFlow(t, testServer, sa, secret, redirect, testCase.expectBadRequest)
+
+			var evList2 *corev1.EventList
+			err = wait.PollImmediate(200*time.Millisecond, 5*time.Second, func() (bool, error) {
+				evList2, err = testServer.clusterAdminKubeClient.CoreV1().Events(projectName).List(metav1.ListOptions{})
+				return err == nil, err
+			})
+			if err != nil {
+				t.Fatalf("%s: err polling for events after second reconcile: %s", tcName, err)
+			}
+			if len(evList2.Items) != len(testCase.expectedEvents) {
+				t.Fatalf("%s: expected the second reconcile to aggregate into the existing %d events, found %d", tcName, len(testCase.expectedEvents), len(evList2.Items))
+			}
 		}
 
 		err = testServer.clusterAdminKubeClient.CoreV1().Events(projectName).DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{})
 		if err != nil {
 			t.Fatalf("%s: error deleting events: %s", tcName, err)
 		}
+
+		if testCase.ingress != nil {
+			testServer.clusterAdminKubeClient.NetworkingV1().Ingresses(projectName).Delete(testCase.ingress.Name, nil)
+		}
+		if testCase.service != nil {
+			testServer.clusterAdminKubeClient.CoreV1().Services(projectName).Delete(testCase.service.Name, nil)
+		}
+	}
+}
+
+// assertExpectedEvents checks that evList contains exactly the expected set of per-annotation
+// and terminal events, matching on reason, message, and the annotation's fieldPath.
+func assertExpectedEvents(t *testing.T, tcName string, evList *corev1.EventList, expected []expectedEvent) {
+	if len(evList.Items) != len(expected) {
+		t.Fatalf("%s: expected %d events, found %d: %#v", tcName, len(expected), len(evList.Items), evList.Items)
+	}
+
+	for _, want := range expected {
+		found := false
+		for _, ev := range collectEventsWithReason(evList, want.reason) {
+			if ev.Message == want.message && ev.InvolvedObject.FieldPath == want.fieldPath {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("%s: expected an event with reason %s, message %q and fieldPath %q, found %#v", tcName, want.reason, want.message, want.fieldPath, evList.Items)
+		}
+	}
+}
+
+// assertResolvedRedirectURIs calls oauthserviceaccountclient.ResolveRedirectURIs directly against
+// sa and asserts it returns exactly want, order-insensitive. This proves the Ingress/Service
+// reference on sa resolves to the right redirect URIs on its own, independent of any
+// includeCallbackURI literal annotation the HTTP flow actually redirects against (that literal
+// exists only because the resolved host isn't dialable from this test's httptest client). That
+// literal is removed from a copy of sa before resolving, so only the reference contributes.
+func assertResolvedRedirectURIs(t *testing.T, tcName string, kubeClient kubernetes.Interface, sa *corev1.ServiceAccount, want []string) {
+	refOnly := sa.DeepCopy()
+	delete(refOnly.Annotations, oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix+"callback")
+
+	var gotBad []string
+	got := oauthserviceaccountclient.ResolveRedirectURIs(refOnly, oauthserviceaccountclient.Clients{Kube: kubeClient}, func(reason, message, fieldPath string) {
+		gotBad = append(gotBad, reason+": "+message)
+	})
+	if len(gotBad) > 0 {
+		t.Fatalf("%s: ResolveRedirectURIs reported unexpected failures: %v", tcName, gotBad)
+	}
+
+	sortedGot := append([]string(nil), got...)
+	sortedWant := append([]string(nil), want...)
+	sort.Strings(sortedGot)
+	sort.Strings(sortedWant)
+	if !reflect.DeepEqual(sortedGot, sortedWant) {
+		t.Fatalf("%s: ResolveRedirectURIs returned %v, want %v", tcName, got, want)
+	}
+}
+
+// singlePathIngressRule builds an IngressRuleValue with a single backend path, enough to
+// exercise OAuthRedirectReference resolution against an Ingress without pulling in a real
+// Service backend.
+func singlePathIngressRule(path string) networkingv1.IngressRuleValue {
+	return networkingv1.IngressRuleValue{
+		HTTP: &networkingv1.HTTPIngressRuleValue{
+			Paths: []networkingv1.HTTPIngressPath{
+				{
+					Path: path,
+					Backend: networkingv1.IngressBackend{
+						Service: &networkingv1.IngressServiceBackend{
+							Name: "svc1",
+							Port: networkingv1.ServiceBackendPort{Number: 443},
+						},
+					},
+				},
+			},
+		},
 	}
 }
 
@@ -260,7 +469,19 @@ func setupTestOAuthServer() (*testServer, error) {
 	}, nil
 }
 
-func setupTestSA(client kubernetes.Interface, annotationPrefix, annotation string) (*corev1.ServiceAccount, error) {
+func setupTestSA(client kubernetes.Interface, annotationPrefix, annotation string, extraAnnotations ...map[string]string) (*corev1.ServiceAccount, error) {
+	annotations := map[string]string{annotationPrefix: annotation}
+	for _, extra := range extraAnnotations {
+		for k, v := range extra {
+			annotations[k] = v
+		}
+	}
+	return setupServiceAccountAnnotations(client, annotations)
+}
+
+// setupServiceAccountAnnotations overrides the default SA's annotations with the given set,
+// always including the challenge-client annotation the OAuth flow in this package relies on.
+func setupServiceAccountAnnotations(client kubernetes.Interface, annotations map[string]string) (*corev1.ServiceAccount, error) {
 	var serviceAccount *corev1.ServiceAccount
 
 	// retry this a couple times.  We seem to be flaking on update conflicts and missing secrets all together
@@ -272,10 +493,12 @@ func setupTestSA(client kubernetes.Interface, annotationPrefix, annotation strin
 		}
 
 		// Each test needs a fresh set of annotations, so override the previous ones.
-		serviceAccount.Annotations = map[string]string{}
-
-		serviceAccount.Annotations[annotationPrefix] = annotation
-		serviceAccount.Annotations[oauthserviceaccountclient.OAuthWantChallengesAnnotationPrefix] = "true"
+		serviceAccount.Annotations = map[string]string{
+			oauthserviceaccountclient.OAuthWantChallengesAnnotationPrefix: "true",
+		}
+		for k, v := range annotations {
+			serviceAccount.Annotations[k] = v
+		}
 		serviceAccount, err = client.CoreV1().ServiceAccounts(projectName).Update(serviceAccount)
 		return err
 	})
@@ -347,16 +570,14 @@ func doOAuthFlow(
 	expectBadRequest bool,
 	expectOperations []string,
 ) {
-	drain(authorizationCodes)
-	drain(authorizationErrors)
-
 	oauthRuntimeClient, err := osincli.NewClient(oauthClientConfig)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	clientTransport, err := restclient.TransportFor(clusterAdminClientConfig)
-	testTransport := &basicAuthTransport{rt: clientTransport}
-	oauthRuntimeClient.Transport = testTransport
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	authorizeRequest := oauthRuntimeClient.NewAuthorizeRequest(osincli.CODE)
 	req, err := http.NewRequest("GET", authorizeRequest.GetAuthorizeUrlWithParams("").String(), nil)
@@ -364,15 +585,45 @@ func doOAuthFlow(
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	// Set up the HTTP redirect handler
 	operations := []string{}
+	doOAuthAuthorize(t, clientTransport, req, authorizationCodes, authorizationErrors, expectBadRequest, func(op string) {
+		operations = append(operations, op)
+	})
+
+	if !reflect.DeepEqual(operations, expectOperations) {
+		t.Errorf("Expected:\n%#v\nGot\n%#v", expectOperations, operations)
+	}
+}
+
+// doOAuthAuthorize drives req through the challenge/approve/redirect dance common to every
+// authorize-code flow this package exercises: a 401 triggers a basic-auth retry, an intervening
+// approval form is submitted, and redirects are followed automatically until either the terminal
+// redirect back to the client's redirect_uri or (when expectBadRequest) a 400/403 is reached. If
+// recordOp is non-nil, each step ("GET /path", "received challenge", "form", "redirect to
+// /path", "code"/"error:...") is reported through it so callers can assert the exact sequence, as
+// doOAuthFlow does. Returns the authorization code, or "" if no code was produced.
+func doOAuthAuthorize(
+	t *testing.T,
+	clientTransport http.RoundTripper,
+	req *http.Request,
+	authorizationCodes chan string,
+	authorizationErrors chan string,
+	expectBadRequest bool,
+	recordOp func(op string),
+) string {
+	drain(authorizationCodes)
+	drain(authorizationErrors)
+
+	testTransport := &basicAuthTransport{rt: clientTransport}
 	jar, _ := cookiejar.New(nil)
 	directHTTPClient := &http.Client{
 		Transport: testTransport,
 		CheckRedirect: func(redirectReq *http.Request, via []*http.Request) error {
 			t.Logf("302 Location: %s", redirectReq.URL.String())
 			req = redirectReq
-			operations = append(operations, "redirect to "+redirectReq.URL.Path)
+			if recordOp != nil {
+				recordOp("redirect to " + redirectReq.URL.Path)
+			}
 			return nil
 		},
 		Jar: jar,
@@ -380,14 +631,16 @@ func doOAuthFlow(
 
 	for {
 		t.Logf("%s %s", req.Method, req.URL.String())
-		operations = append(operations, req.Method+" "+req.URL.Path)
+		if recordOp != nil {
+			recordOp(req.Method + " " + req.URL.Path)
+		}
 
 		// Always set the csrf header
 		req.Header.Set("X-CSRF-Token", "1")
 		resp, err := directHTTPClient.Do(req)
 		if err != nil {
-			t.Errorf("Error %v\n%#v\n%#v", operations, jar, err)
-			return
+			t.Errorf("Error %v", err)
+			return ""
 		}
 		defer resp.Body.Close()
 
@@ -398,33 +651,35 @@ func doOAuthFlow(
 			// Set up a username and password once we're challenged
 			testTransport.username = adminUser
 			testTransport.password = "any-pass"
-			operations = append(operations, "received challenge")
+			if recordOp != nil {
+				recordOp("received challenge")
+			}
 			continue
 		}
 
-		if expectBadRequest && resp.StatusCode == 400 {
+		if expectBadRequest && (resp.StatusCode == 400 || resp.StatusCode == 403) {
 			responseDump, _ := httputil.DumpResponse(resp, true)
 			t.Logf("Bad Request: %s", string(responseDump))
-			return
+			return ""
 		}
 
 		if resp.StatusCode != 200 {
 			responseDump, _ := httputil.DumpResponse(resp, true)
 			t.Errorf("Expected status code 200, got %v and response: %s", resp.StatusCode, string(responseDump))
-			return
+			return ""
 		}
 
 		doc, err := html.Parse(resp.Body)
 		if err != nil {
 			responseDump, _ := httputil.DumpResponse(resp, true)
 			t.Errorf("Error parsing response body: %s", string(responseDump))
-			return
+			return ""
 		}
 		forms := htmlutil.GetElementsByTagName(doc, "form")
 		// if there's a single form, submit it
 		if len(forms) > 1 {
 			t.Errorf("More than one form encountered: %d", len(forms))
-			return
+			return ""
 		}
 		if len(forms) == 0 {
 			break
@@ -432,21 +687,29 @@ func doOAuthFlow(
 		req, err = htmlutil.NewRequestFromForm(forms[0], currentURL, nil)
 		if err != nil {
 			t.Errorf("Error creating form response: %s", err)
-			return
+			return ""
+		}
+		if recordOp != nil {
+			recordOp("form")
 		}
-		operations = append(operations, "form")
 	}
 
 	select {
-	case <-authorizationCodes:
-		operations = append(operations, "code")
+	case code := <-authorizationCodes:
+		if recordOp != nil {
+			recordOp("code")
+		}
+		return code
 	case authorizationError := <-authorizationErrors:
-		operations = append(operations, "error:"+authorizationError)
+		if recordOp != nil {
+			recordOp("error:" + authorizationError)
+		}
+		if !expectBadRequest {
+			t.Errorf("unexpected authorization error: %s", authorizationError)
+		}
+		return ""
 	case <-time.After(5 * time.Second):
 		t.Error("didn't get a code or an error")
-	}
-
-	if !reflect.DeepEqual(operations, expectOperations) {
-		t.Errorf("Expected:\n%#v\nGot\n%#v", expectOperations, operations)
+		return ""
 	}
 }
@@ -0,0 +1,199 @@
+package integration
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	apiserverserviceaccount "k8s.io/apiserver/pkg/authentication/serviceaccount"
+	restclient "k8s.io/client-go/rest"
+
+	"github.com/openshift/library-go/pkg/oauth/oauthserviceaccountclient"
+	"github.com/openshift/oauth-server/pkg/scopecovers"
+	testserver "github.com/openshift/origin/test/util/server"
+)
+
+// TestOAuthServiceAccountClientPKCE verifies that a service account which opts in to PKCE via
+// the serviceaccounts.openshift.io/oauth-require-pkce annotation is forced through a proof-key
+// exchange: /oauth/authorize must be given a code_challenge, and /oauth/token must reject a
+// missing, wrong, or downgraded code_verifier.
+func TestOAuthServiceAccountClientPKCE(t *testing.T) {
+	const verifier = "this-is-a-sufficiently-long-and-random-code-verifier-0123456789"
+	s256Challenge := s256CodeChallenge(verifier)
+
+	tests := map[string]struct {
+		challengeMethod  string
+		challenge        string
+		verifier         string
+		expectBadRequest bool
+		expectPKCEEvent  bool
+	}{
+		"s256 happy path": {
+			challengeMethod: "S256",
+			challenge:       s256Challenge,
+			verifier:        verifier,
+		},
+		"plain happy path": {
+			challengeMethod: "plain",
+			challenge:       verifier,
+			verifier:        verifier,
+		},
+		"missing challenge": {
+			expectBadRequest: true,
+			expectPKCEEvent:  true,
+		},
+		"missing verifier": {
+			challengeMethod:  "S256",
+			challenge:        s256Challenge,
+			expectBadRequest: true,
+		},
+		"wrong verifier": {
+			challengeMethod:  "S256",
+			challenge:        s256Challenge,
+			verifier:         "not-the-verifier-that-was-used-to-build-the-challenge",
+			expectBadRequest: true,
+		},
+		"downgraded method": {
+			// presenting an S256 challenge as plain never matches the verifier
+			challengeMethod:  "plain",
+			challenge:        s256Challenge,
+			verifier:         verifier,
+			expectBadRequest: true,
+		},
+	}
+
+	testServer, err := setupTestOAuthServer()
+	if err != nil {
+		t.Fatalf("error setting up test server: %s", err)
+	}
+	defer testServer.oauthServer.Close()
+	defer testserver.CleanupMasterEtcd(t, testServer.masterConfig)
+
+	for tcName, tc := range tests {
+		t.Run(tcName, func(t *testing.T) {
+			redirect := testServer.oauthServer.URL + "/oauthcallback"
+			sa, err := setupTestSA(testServer.clusterAdminKubeClient, oauthserviceaccountclient.OAuthRedirectModelAnnotationURIPrefix+"one", redirect,
+				map[string]string{oauthserviceaccountclient.OAuthRequirePKCEAnnotation: "true"})
+			if err != nil {
+				t.Fatalf("error setting up test SA: %s", err)
+			}
+
+			secret, err := setupTestSecrets(testServer.clusterAdminKubeClient, sa)
+			if err != nil {
+				t.Fatalf("error setting up test secrets: %s", err)
+			}
+
+			runTestOAuthPKCEFlow(t, testServer, sa, secret, redirect, tc.challengeMethod, tc.challenge, tc.verifier, tc.expectBadRequest)
+
+			if tc.expectPKCEEvent {
+				var evList *corev1.EventList
+				err = wait.Poll(time.Second, 5*time.Second, func() (bool, error) {
+					evList, err = testServer.clusterAdminKubeClient.CoreV1().Events(projectName).List(metav1.ListOptions{})
+					if err != nil {
+						return false, err
+					}
+					return len(collectEventsWithReason(evList, "PKCERequired")) > 0, nil
+				})
+				if err != nil {
+					t.Fatalf("%s: expected a PKCERequired event but found none", tcName)
+				}
+			}
+
+			if err := testServer.clusterAdminKubeClient.CoreV1().Events(projectName).DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{}); err != nil {
+				t.Fatalf("%s: error deleting events: %s", tcName, err)
+			}
+			testServer.clusterAdminOAuthClient.OAuthClientAuthorizations().Delete(adminUser+":"+apiserverserviceaccount.MakeUsername(sa.Namespace, sa.Name), nil)
+		})
+	}
+}
+
+// runTestOAuthPKCEFlow drives the same authorize/approve dance as runTestOAuthFlow (via the
+// shared doOAuthAuthorize helper), but attaches a code_challenge to the initial authorize request
+// and then exchanges the resulting code for a token using the given code_verifier, asserting that
+// /oauth/token accepts or rejects it as expected.
+func runTestOAuthPKCEFlow(
+	t *testing.T,
+	ts *testServer,
+	sa *corev1.ServiceAccount,
+	secret *corev1.Secret,
+	redirectURL string,
+	challengeMethod string,
+	challenge string,
+	verifier string,
+	expectBadRequest bool,
+) {
+	clientID := apiserverserviceaccount.MakeUsername(sa.Namespace, sa.Name)
+	clientSecret := string(secret.Data[corev1.ServiceAccountTokenKey])
+
+	authorizeURL, err := url.Parse(ts.clusterAdminClientConfig.Host + "/oauth/authorize")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q := authorizeURL.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURL)
+	q.Set("scope", scopecovers.Join([]string{"user:info", "role:edit:" + projectName}))
+	if len(challenge) > 0 {
+		q.Set("code_challenge", challenge)
+		q.Set("code_challenge_method", challengeMethod)
+	}
+	authorizeURL.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", authorizeURL.String(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	clientTransport, err := restclient.TransportFor(ts.clusterAdminClientConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	code := doOAuthAuthorize(t, clientTransport, req, ts.authCodes, ts.authErrors, expectBadRequest, nil)
+	if len(code) == 0 {
+		if expectBadRequest {
+			return
+		}
+		t.Fatalf("expected an authorization code but got none")
+	}
+
+	tokenURL := ts.clusterAdminClientConfig.Host + "/oauth/token"
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+	if len(verifier) > 0 {
+		form.Set("code_verifier", verifier)
+	}
+
+	tokenResp, err := http.PostForm(tokenURL, form)
+	if err != nil {
+		t.Fatalf("unexpected error exchanging code for a token: %v", err)
+	}
+	defer tokenResp.Body.Close()
+
+	if expectBadRequest {
+		if tokenResp.StatusCode == http.StatusOK {
+			t.Fatalf("expected the token exchange to fail due to PKCE verification, but it succeeded")
+		}
+		return
+	}
+
+	if tokenResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a successful token exchange, got status %v", tokenResp.StatusCode)
+	}
+}
+
+func s256CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
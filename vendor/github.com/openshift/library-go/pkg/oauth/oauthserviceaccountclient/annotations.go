@@ -0,0 +1,25 @@
+package oauthserviceaccountclient
+
+// Annotation prefixes/keys a service account uses to opt in to being treated as an OAuth client.
+// A service account may carry any number of keys sharing the URI or reference prefix below, each
+// contributing zero or more redirect URIs once resolved.
+const (
+	// OAuthRedirectModelAnnotationURIPrefix annotations carry a literal redirect URI, e.g.
+	// serviceaccounts.openshift.io/oauth-redirecturi.one=https://example.com/callback.
+	OAuthRedirectModelAnnotationURIPrefix = "serviceaccounts.openshift.io/oauth-redirecturi."
+
+	// OAuthRedirectModelAnnotationReferencePrefix annotations carry a JSON-encoded
+	// OAuthRedirectReference pointing at another object (a Route, Ingress, or Service) whose
+	// host(s) should be resolved into one or more redirect URIs.
+	OAuthRedirectModelAnnotationReferencePrefix = "serviceaccounts.openshift.io/oauth-redirectreference."
+
+	// OAuthWantChallengesAnnotationPrefix, set to "true", asks the OAuth server to challenge
+	// for credentials (WWW-Authenticate) rather than only accepting a bearer token.
+	OAuthWantChallengesAnnotationPrefix = "serviceaccounts.openshift.io/oauth-want-challenges"
+
+	// OAuthRequirePKCEAnnotation, set to "true", requires every authorization request for
+	// this service-account client to include a PKCE code_challenge (RFC 7636). Clients that
+	// omit one are rejected at /oauth/authorize with a PKCERequired event instead of being
+	// silently allowed through without a proof key.
+	OAuthRequirePKCEAnnotation = "serviceaccounts.openshift.io/oauth-require-pkce"
+)
@@ -0,0 +1,94 @@
+package oauthserviceaccountclient
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Controller watches ServiceAccounts and, for each add/update, calls
+// ResolveRedirectURIsAndRecordEvents so a service account carrying a broken
+// oauth-redirecturi.*/oauth-redirectreference.* annotation gets a warning event instead of
+// silently being rejected as an OAuth client. An OAuth server wires this up the same way it
+// wires any other core-resource controller: hand NewController a SharedIndexInformer over
+// ServiceAccounts and call Run from its start-up path.
+type Controller struct {
+	clients  Clients
+	recorder record.EventRecorder
+
+	informer cache.SharedIndexInformer
+	queue    workqueue.RateLimitingInterface
+}
+
+// NewController returns a Controller that has registered its event handlers on informer but has
+// not yet started processing; call Run to begin.
+func NewController(informer cache.SharedIndexInformer, clients Clients, recorder record.EventRecorder) *Controller {
+	c := &Controller{
+		clients:  clients,
+		recorder: recorder,
+		informer: informer,
+		queue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "sa-oauth-client"),
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(_, newObj interface{}) { c.enqueue(newObj) },
+	})
+	return c
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	c.queue.Add(key)
+}
+
+// Run blocks reconciling ServiceAccounts with workers goroutines until stopCh is closed.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer c.queue.ShutDown()
+
+	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
+		return
+	}
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+	<-stopCh
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	obj, exists, err := c.informer.GetStore().GetByKey(key.(string))
+	if err != nil {
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	if !exists {
+		c.queue.Forget(key)
+		return true
+	}
+	sa, ok := obj.(*corev1.ServiceAccount)
+	if !ok {
+		c.queue.Forget(key)
+		return true
+	}
+
+	ResolveRedirectURIsAndRecordEvents(sa, c.clients, c.recorder)
+	c.queue.Forget(key)
+	return true
+}
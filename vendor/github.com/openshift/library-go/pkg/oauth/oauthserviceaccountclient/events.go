@@ -0,0 +1,74 @@
+package oauthserviceaccountclient
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventDedupWindow bounds how often ResolveRedirectURIsAndRecordEvents will re-emit the same
+// (service account UID and resourceVersion, reason, message, fieldPath) warning. Controller's reconcile loop runs on every
+// add/update of a watched ServiceAccount, which for a persistently broken annotation means every
+// resync - without this, that's a fresh event into etcd on every single pass.
+const eventDedupWindow = 10 * time.Minute
+
+// ResolveRedirectURIsAndRecordEvents is called by Controller's reconcile loop (see
+// controller.go) for each ServiceAccount it watches: it resolves sa's redirect URIs and, for
+// every annotation that fails to resolve, records a warning event against sa - one event per bad
+// key, using the fieldPath to point straight at the offending annotation instead of a single
+// collapsed message that hides which annotation caused the failure. A final
+// NoSAOAuthRedirectURIs event is recorded if zero annotations resolved. A repeat call for the
+// same sa at the same resourceVersion within eventDedupWindow that would produce an identical
+// (reason, message, fieldPath) is suppressed by defaultDeduper - this function rate-limits its own output rather than relying
+// on whatever aggregation recorder happens to apply - so a persistently broken annotation doesn't
+// get a fresh event every reconcile.
+func ResolveRedirectURIsAndRecordEvents(sa *corev1.ServiceAccount, clients Clients, recorder record.EventRecorder) []string {
+	return ResolveRedirectURIs(sa, clients, func(reason, message, fieldPath string) {
+		if !defaultDeduper.shouldRecord(sa.UID, sa.ResourceVersion, reason, message, fieldPath) {
+			return
+		}
+		recorder.Event(&corev1.ObjectReference{
+			Kind:       "ServiceAccount",
+			Namespace:  sa.Namespace,
+			Name:       sa.Name,
+			UID:        sa.UID,
+			APIVersion: "v1",
+			FieldPath:  fieldPath,
+		}, corev1.EventTypeWarning, reason, message)
+	})
+}
+
+// defaultDeduper is the package-level dedup state ResolveRedirectURIsAndRecordEvents consults;
+// a single Controller reconciles for the life of the process, so a package-level instance -
+// rather than one scoped to a single call - is what actually suppresses repeats across resyncs.
+var defaultDeduper = newEventDeduper()
+
+// eventDeduper remembers the last time a given (service account UID and resourceVersion,
+// reason, message, fieldPath) was recorded, so reconciling the same unchanged broken
+// ServiceAccount over and over doesn't spam an identical event into etcd on every pass.
+type eventDeduper struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	now  func() time.Time
+}
+
+func newEventDeduper() *eventDeduper {
+	return &eventDeduper{seen: map[string]time.Time{}, now: time.Now}
+}
+
+func (d *eventDeduper) shouldRecord(uid types.UID, resourceVersion, reason, message, fieldPath string) bool {
+	key := string(uid) + "\x00" + resourceVersion + "\x00" + reason + "\x00" + message + "\x00" + fieldPath
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if last, ok := d.seen[key]; ok && now.Sub(last) < eventDedupWindow {
+		return false
+	}
+	d.seen[key] = now
+	return true
+}
@@ -0,0 +1,79 @@
+package oauthserviceaccountclient
+
+import (
+	"fmt"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+
+	oauthv1 "github.com/openshift/api/oauth/v1"
+)
+
+// redirectReferenceScheme exists only to decode the
+// serviceaccounts.openshift.io/oauth-redirectreference.* annotation payloads. It is kept
+// separate from any broader API server scheme so that a service account carrying a malformed
+// or unrecognized reference kind can never affect decoding anywhere else.
+var (
+	redirectReferenceScheme = runtime.NewScheme()
+	redirectReferenceCodecs = serializer.NewCodecFactory(redirectReferenceScheme)
+)
+
+func init() {
+	utilruntime.Must(oauthv1.Install(redirectReferenceScheme))
+}
+
+// decodeRedirectReference decodes the JSON payload of a
+// serviceaccounts.openshift.io/oauth-redirectreference.* annotation into an
+// OAuthRedirectReference. Decode failures (bad JSON, or a kind/version unknown to
+// redirectReferenceScheme) are returned verbatim, with stripSchemeLocation applied, so callers
+// can surface them as BadRedirectReferenceJSON events.
+func decodeRedirectReference(data []byte) (*oauthv1.OAuthRedirectReference, error) {
+	obj, _, err := redirectReferenceCodecs.UniversalDecoder(oauthv1.SchemeGroupVersion).Decode(data, nil, nil)
+	if err != nil {
+		return nil, stripSchemeLocation(err)
+	}
+	ref, ok := obj.(*oauthv1.OAuthRedirectReference)
+	if !ok {
+		return nil, fmt.Errorf("decoded %T, not an OAuthRedirectReference", obj)
+	}
+	return ref, nil
+}
+
+// stripSchemeLocation drops the ` in scheme "<file>:<line>"` suffix runtime.NewScheme() bakes
+// into "no kind registered" errors from the call site that constructed redirectReferenceScheme.
+// Without this, the exact line of this file that happens to call runtime.NewScheme() (the
+// redirectReferenceScheme var above) leaks into an error message a caller might match on, so any incidental
+// reformatting of this file (a new import, a reordered var) would silently break that match.
+func stripSchemeLocation(err error) error {
+	msg := err.Error()
+	if idx := strings.Index(msg, ` in scheme "`); idx >= 0 {
+		return fmt.Errorf("%s", msg[:idx])
+	}
+	return err
+}
+
+// supportedRedirectReferenceKinds enumerates the Reference.Kind values this package knows how
+// to resolve into redirect URIs.
+var supportedRedirectReferenceKinds = map[string]bool{
+	"Route":   true,
+	"Ingress": true,
+	"Service": true,
+}
+
+// redirectReferenceGroup returns the API group a given Reference.Kind is expected to live in,
+// used to validate the Reference.Group field on an otherwise well-formed reference.
+func redirectReferenceGroup(kind string) string {
+	switch kind {
+	case "Route":
+		return "route.openshift.io"
+	case "Ingress":
+		return networkingv1.GroupName
+	case "Service":
+		return ""
+	default:
+		return ""
+	}
+}
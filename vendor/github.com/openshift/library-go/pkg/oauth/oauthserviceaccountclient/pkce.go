@@ -0,0 +1,42 @@
+package oauthserviceaccountclient
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ReasonPKCERequired is recorded against a service account when its OAuth client requires PKCE
+// (see OAuthRequirePKCEAnnotation) and an /oauth/authorize request arrives with no
+// code_challenge.
+const ReasonPKCERequired = "PKCERequired"
+
+// RequiresPKCE reports whether sa has opted in to mandatory PKCE for its OAuth client.
+func RequiresPKCE(sa *corev1.ServiceAccount) bool {
+	return sa.Annotations[OAuthRequirePKCEAnnotation] == "true"
+}
+
+// VerifyCodeChallenge implements the RFC 7636 §4.6 comparison between the code_challenge
+// recorded at /oauth/authorize time and the code_verifier presented at /oauth/token time. An
+// empty method is treated as "plain", its RFC-specified default.
+func VerifyCodeChallenge(method, challenge, verifier string) bool {
+	if len(challenge) == 0 {
+		return len(verifier) == 0
+	}
+	if len(verifier) == 0 {
+		return false
+	}
+
+	switch method {
+	case "", "plain":
+		return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+	case "S256":
+		sum := sha256.Sum256([]byte(verifier))
+		computed := base64.RawURLEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(challenge), []byte(computed)) == 1
+	default:
+		return false
+	}
+}
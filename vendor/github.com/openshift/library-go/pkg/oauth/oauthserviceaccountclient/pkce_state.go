@@ -0,0 +1,104 @@
+package oauthserviceaccountclient
+
+import (
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PendingAuthorization is the PKCE state an /oauth/authorize implementation must carry forward
+// from the authorization request to the token exchange, keyed by the issued authorization code.
+type PendingAuthorization struct {
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// PendingAuthorizationStore persists PendingAuthorization state between /oauth/authorize issuing
+// a code and /oauth/token exchanging it. A real OAuth server backs this with whatever it already
+// uses to store authorization grants (etcd, an in-memory TTL cache, ...); ValidateAuthorizeRequest
+// and ValidateTokenExchange only need Put/Get/Delete.
+type PendingAuthorizationStore interface {
+	Put(code string, pending PendingAuthorization)
+	Get(code string) (PendingAuthorization, bool)
+	Delete(code string)
+}
+
+// NewInMemoryPendingAuthorizationStore returns a PendingAuthorizationStore suitable for a
+// single-instance OAuth server or for tests; it never expires entries on its own; callers must
+// call Delete once a code has been exchanged (or has expired).
+func NewInMemoryPendingAuthorizationStore() PendingAuthorizationStore {
+	return &inMemoryPendingAuthorizationStore{pending: map[string]PendingAuthorization{}}
+}
+
+type inMemoryPendingAuthorizationStore struct {
+	mu      sync.Mutex
+	pending map[string]PendingAuthorization
+}
+
+func (s *inMemoryPendingAuthorizationStore) Put(code string, pending PendingAuthorization) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[code] = pending
+}
+
+func (s *inMemoryPendingAuthorizationStore) Get(code string) (PendingAuthorization, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, ok := s.pending[code]
+	return pending, ok
+}
+
+func (s *inMemoryPendingAuthorizationStore) Delete(code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, code)
+}
+
+// ValidateAuthorizeRequest is the hook an /oauth/authorize implementation calls once it knows
+// which service account the request's client_id resolves to. If sa requires PKCE and the request
+// carried no code_challenge, it returns a ReasonPKCERequired error the caller should both surface
+// to the client as an invalid_request and record as an event. Otherwise, on success, it records
+// the challenge against code in store so ValidateTokenExchange can verify it later.
+func ValidateAuthorizeRequest(sa *corev1.ServiceAccount, code, codeChallenge, codeChallengeMethod string, store PendingAuthorizationStore) error {
+	if RequiresPKCE(sa) && len(codeChallenge) == 0 {
+		return &PKCEError{Reason: ReasonPKCERequired, Message: fmt.Sprintf(
+			"client %s requires a PKCE code_challenge (see %s)", sa.Name, OAuthRequirePKCEAnnotation)}
+	}
+	if len(codeChallenge) > 0 {
+		store.Put(code, PendingAuthorization{CodeChallenge: codeChallenge, CodeChallengeMethod: codeChallengeMethod})
+	}
+	return nil
+}
+
+// ValidateTokenExchange is the hook an /oauth/token implementation calls before honoring an
+// authorization_code grant. It looks up any PendingAuthorization recorded for code and verifies
+// verifier against it with VerifyCodeChallenge; a mismatch (or a missing verifier when a
+// challenge was recorded) is returned as an invalid_grant-shaped PKCEError. The pending entry is
+// consumed (deleted) either way, since an authorization code is single-use.
+func ValidateTokenExchange(code, verifier string, store PendingAuthorizationStore) error {
+	pending, ok := store.Get(code)
+	defer store.Delete(code)
+	if !ok {
+		if len(verifier) > 0 {
+			return &PKCEError{Reason: "invalid_grant", Message: "code_verifier presented for a code that did not record a code_challenge"}
+		}
+		return nil
+	}
+	if !VerifyCodeChallenge(pending.CodeChallengeMethod, pending.CodeChallenge, verifier) {
+		return &PKCEError{Reason: "invalid_grant", Message: "code_verifier does not match the code_challenge recorded at /oauth/authorize"}
+	}
+	return nil
+}
+
+// PKCEError is returned by ValidateAuthorizeRequest and ValidateTokenExchange. Reason is either
+// ReasonPKCERequired (authorize time) or the OAuth2 error code "invalid_grant" (token exchange
+// time); callers map it to the corresponding HTTP response and, for ReasonPKCERequired, an event.
+type PKCEError struct {
+	Reason  string
+	Message string
+}
+
+func (e *PKCEError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,203 @@
+package oauthserviceaccountclient
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	routev1client "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
+)
+
+// Event reasons recorded against a misconfigured OAuth-client service account. Each bad
+// annotation gets its own event using one of these reasons; ReasonNoSAOAuthRedirectURIs is the
+// terminal reason emitted once, only when zero valid redirect URIs remain after considering
+// every annotation.
+const (
+	ReasonBadRedirectURI           = "BadRedirectURI"
+	ReasonBadRedirectReferenceJSON = "BadRedirectReferenceJSON"
+	ReasonBadRedirectReferenceKind = "BadRedirectReferenceKind"
+	ReasonRedirectTargetNotFound   = "RedirectTargetNotFound"
+	ReasonRedirectTargetWrongGroup = "RedirectTargetWrongGroup"
+	ReasonNoSAOAuthRedirectURIs    = "NoSAOAuthRedirectURIs"
+)
+
+// Clients bundles the clients needed to resolve OAuthRedirectReference targets. Callers (the
+// OAuth server's service-account-client adapter) construct one of these per request from their
+// existing listers/clientsets.
+type Clients struct {
+	Kube   kubernetes.Interface
+	Routes routev1client.RouteV1Interface
+}
+
+// ResolveRedirectURIs walks every oauth-redirecturi.* / oauth-redirectreference.* annotation on
+// sa, in sorted key order, and returns the redirect URIs that resolve successfully. An
+// annotation that fails to resolve does not abort the call - it is reported once through
+// recordEvent (reason, message, the annotation's metadata.annotations[<key>] fieldPath) so a
+// single bad entry never masks the others. If zero URIs resolve overall, a final
+// ReasonNoSAOAuthRedirectURIs event is recorded with an empty fieldPath.
+func ResolveRedirectURIs(sa *corev1.ServiceAccount, clients Clients, recordEvent func(reason, message, fieldPath string)) []string {
+	var keys []string
+	for k := range sa.Annotations {
+		if strings.HasPrefix(k, OAuthRedirectModelAnnotationURIPrefix) || strings.HasPrefix(k, OAuthRedirectModelAnnotationReferencePrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var uris []string
+	for _, key := range keys {
+		value := sa.Annotations[key]
+		fieldPath := fmt.Sprintf("metadata.annotations[%s]", key)
+
+		var (
+			resolved []string
+			reason   string
+			err      error
+		)
+		if strings.HasPrefix(key, OAuthRedirectModelAnnotationURIPrefix) {
+			reason = ReasonBadRedirectURI
+			var uri string
+			uri, err = parseRedirectURI(value)
+			if err == nil {
+				resolved = []string{uri}
+			}
+		} else {
+			resolved, reason, err = resolveRedirectReference(sa.Namespace, value, clients)
+		}
+
+		if err != nil {
+			recordEvent(reason, err.Error(), fieldPath)
+			continue
+		}
+		uris = append(uris, resolved...)
+	}
+
+	if len(uris) == 0 {
+		recordEvent(ReasonNoSAOAuthRedirectURIs, noRedirectURIsMessage(sa), "")
+	}
+
+	return uris
+}
+
+func noRedirectURIsMessage(sa *corev1.ServiceAccount) string {
+	return fmt.Sprintf(
+		"system:serviceaccount:%s:%s has no redirectURIs; set %s<some-value>=<redirect> or create a dynamic URI using %s<some-value>=<reference>",
+		sa.Namespace, sa.Name, OAuthRedirectModelAnnotationURIPrefix, OAuthRedirectModelAnnotationReferencePrefix,
+	)
+}
+
+// parseRedirectURI accepts either an absolute URI (scheme://host/path) or a bare path, which is
+// resolved relative to the client's requested redirect_uri by the OAuth server. Anything that
+// fails to parse, or that has a scheme followed by an opaque part instead of a host (e.g.
+// "foo:foo"), is rejected; a scheme with no host and an empty opaque part is accepted.
+func parseRedirectURI(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			return "", fmt.Errorf("parse %s: %s", urlErr.URL, urlErr.Err)
+		}
+		return "", err
+	}
+	if len(u.Scheme) > 0 && len(u.Host) == 0 && len(u.Opaque) > 0 {
+		return "", fmt.Errorf("%q is not a valid redirect URI", raw)
+	}
+	return raw, nil
+}
+
+// resolveRedirectReference decodes value as an OAuthRedirectReference and resolves its target
+// into zero or more redirect URIs.
+func resolveRedirectReference(namespace, value string, clients Clients) ([]string, string, error) {
+	ref, err := decodeRedirectReference([]byte(value))
+	if err != nil {
+		return nil, ReasonBadRedirectReferenceJSON, err
+	}
+
+	kind := ref.Reference.Kind
+	if !supportedRedirectReferenceKinds[kind] {
+		return nil, ReasonBadRedirectReferenceKind, fmt.Errorf("%q is not a supported redirect reference kind", kind)
+	}
+	if want := redirectReferenceGroup(kind); ref.Reference.Group != want {
+		return nil, ReasonRedirectTargetWrongGroup, fmt.Errorf("%q is not a valid group for %s", ref.Reference.Group, articleAndKind(kind))
+	}
+
+	switch kind {
+	case "Route":
+		return resolveRoute(namespace, ref.Reference.Name, clients)
+	case "Ingress":
+		return resolveIngress(namespace, ref.Reference.Name, clients)
+	case "Service":
+		return resolveService(namespace, ref.Reference.Name, clients)
+	default:
+		return nil, ReasonBadRedirectReferenceKind, fmt.Errorf("%q is not a supported redirect reference kind", kind)
+	}
+}
+
+func articleAndKind(kind string) string {
+	if kind == "Ingress" {
+		return "an " + kind + " redirect target"
+	}
+	return "a " + kind + " redirect target"
+}
+
+func resolveRoute(namespace, name string, clients Clients) ([]string, string, error) {
+	route, err := clients.Routes.Routes(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, ReasonRedirectTargetNotFound, err
+	}
+	scheme := "http"
+	if route.Spec.TLS != nil {
+		scheme = "https"
+	}
+	return []string{scheme + "://" + route.Spec.Host}, "", nil
+}
+
+func resolveIngress(namespace, name string, clients Clients) ([]string, string, error) {
+	ingress, err := clients.Kube.NetworkingV1().Ingresses(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, ReasonRedirectTargetNotFound, err
+	}
+
+	tlsHosts := map[string]bool{}
+	for _, tls := range ingress.Spec.TLS {
+		for _, host := range tls.Hosts {
+			tlsHosts[host] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	var uris []string
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		scheme := "http"
+		if tlsHosts[rule.Host] {
+			scheme = "https"
+		}
+		for _, path := range rule.HTTP.Paths {
+			uri := scheme + "://" + rule.Host + path.Path
+			if seen[uri] {
+				continue
+			}
+			seen[uri] = true
+			uris = append(uris, uri)
+		}
+	}
+	return uris, "", nil
+}
+
+// resolveService resolves a Service reference to its cluster-internal DNS name, for SA clients
+// whose redirect target is another workload in the same cluster rather than something exposed
+// through a Route or Ingress.
+func resolveService(namespace, name string, clients Clients) ([]string, string, error) {
+	svc, err := clients.Kube.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, ReasonRedirectTargetNotFound, err
+	}
+	return []string{"https://" + svc.Name + "." + svc.Namespace + ".svc"}, "", nil
+}
@@ -0,0 +1,50 @@
+// Package openid provides the pieces of an OpenID Connect provider a server mounts onto its
+// existing /oauth/authorize and /oauth/token handlers so that SA-as-OAuth-client requests
+// carrying scope=openid receive a signed id_token alongside their access token:
+// DiscoveryHandler and JWKSHandler to serve the discovery document and keys, and
+// IssueIDTokenForRequest for the token handler to call. RotatingSigningKeySource sources the
+// RSA signing key from the master's signing config and keeps a rotated-out key published for an
+// overlap window so in-flight tokens keep verifying.
+package openid
+
+// DiscoveryDocument is the subset of the OpenID Provider Metadata
+// (https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata) this server
+// publishes at /.well-known/openid-configuration.
+type DiscoveryDocument struct {
+	Issuer                           string   `json:"issuer"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint"`
+	TokenEndpoint                    string   `json:"token_endpoint"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+// NewDiscoveryDocument builds the discovery document advertised for a master running at
+// masterURL.
+func NewDiscoveryDocument(masterURL string) *DiscoveryDocument {
+	return &DiscoveryDocument{
+		Issuer:                           masterURL,
+		AuthorizationEndpoint:            masterURL + "/oauth/authorize",
+		TokenEndpoint:                    masterURL + "/oauth/token",
+		JWKSURI:                          masterURL + "/keys",
+		ResponseTypesSupported:           []string{"code"},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{"RS256"},
+		ScopesSupported:                  []string{"openid", "profile", "email", "groups"},
+		ClaimsSupported:                  []string{"iss", "sub", "aud", "exp", "iat", "nonce", "groups"},
+	}
+}
+
+// requestsIDToken reports whether the given OAuth scope list (as sent to /oauth/authorize or
+// /oauth/token) includes "openid", the RFC-defined trigger for ID token issuance.
+func requestsIDToken(scopes []string) bool {
+	for _, s := range scopes {
+		if s == "openid" {
+			return true
+		}
+	}
+	return false
+}
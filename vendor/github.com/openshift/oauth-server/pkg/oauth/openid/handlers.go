@@ -0,0 +1,69 @@
+package openid
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// DiscoveryHandler serves the OpenID discovery document at /.well-known/openid-configuration for
+// a master running at masterURL. A server mounts this directly on its router.
+func DiscoveryHandler(masterURL string) http.Handler {
+	doc := NewDiscoveryDocument(masterURL)
+	body, err := json.Marshal(doc)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// JWKSHandler serves the signing keys published at DiscoveryDocument.JWKSURI (conventionally
+// /keys), re-reading keys on every request so a rotation becomes visible without a restart.
+func JWKSHandler(keys SigningKeySource) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := json.Marshal(keys.Keys())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// IssueIDTokenForRequest is the hook an /oauth/token implementation calls after issuing an
+// access token: if scopes includes "openid" it signs and returns an ID token with the given
+// issuer/subject/audience using keys' current signing key, plus the given groups only when scopes
+// also includes "groups"; otherwise it returns "", nil so the caller omits id_token from the
+// response entirely.
+func IssueIDTokenForRequest(keys SigningKeySource, scopes []string, issuer, subject, audience string, groups []string, nonce string, expiresIn time.Duration) (string, error) {
+	if !requestsIDToken(scopes) {
+		return "", nil
+	}
+
+	requestsGroups := false
+	for _, s := range scopes {
+		if s == "groups" {
+			requestsGroups = true
+		}
+	}
+	if !requestsGroups {
+		groups = nil
+	}
+
+	key, kid := keys.Current()
+	now := time.Now()
+	return IssueIDToken(key, kid, Claims{
+		Issuer:   issuer,
+		Subject:  subject,
+		Audience: audience,
+		IssuedAt: now.Unix(),
+		Expiry:   now.Add(expiresIn).Unix(),
+		Nonce:    nonce,
+		Groups:   groups,
+	})
+}
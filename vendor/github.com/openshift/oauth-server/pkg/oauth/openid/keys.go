@@ -0,0 +1,110 @@
+package openid
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SigningKeySource is what JWKSHandler and an /oauth/token implementation need
+// to issue and publish RS256-signed ID tokens: the key to sign the next token with, and the full
+// set of keys (current plus any still within their overlap window) a verifier should trust.
+type SigningKeySource interface {
+	// Current returns the key new ID tokens are signed with, and the kid a verifier should look
+	// it up by in the published JWKS.
+	Current() (key *rsa.PrivateKey, kid string)
+	// Keys returns every public key a verifier should currently accept, including ones rotated
+	// out within the overlap window.
+	Keys() JSONWebKeySet
+}
+
+// retiredKey is a rotated-out signing key kept publishable in Keys() until expiresAt, so ID
+// tokens signed just before a rotation still verify afterward.
+type retiredKey struct {
+	key       *rsa.PrivateKey
+	kid       string
+	expiresAt time.Time
+}
+
+// RotatingSigningKeySource sources its RSA signing key from the master's signing config (via
+// load) and, on Rotate, keeps every outgoing key publishable for its own overlap window, so
+// rotating again before an earlier overlap has elapsed never drops a still-valid key early.
+type RotatingSigningKeySource struct {
+	load func() (*rsa.PrivateKey, error)
+
+	mu         sync.RWMutex
+	current    *rsa.PrivateKey
+	currentKid string
+	retired    []retiredKey
+	overlap    time.Duration
+	nextKid    int
+}
+
+// NewRotatingSigningKeySource builds a RotatingSigningKeySource that loads its initial signing
+// key from load (e.g. the master's configured service-account/OIDC signing key file) and keeps a
+// rotated-out key publishable for overlap after each Rotate call.
+func NewRotatingSigningKeySource(load func() (*rsa.PrivateKey, error), overlap time.Duration) (*RotatingSigningKeySource, error) {
+	key, err := load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load initial OIDC signing key: %w", err)
+	}
+	return &RotatingSigningKeySource{
+		load:       load,
+		current:    key,
+		currentKid: "1",
+		overlap:    overlap,
+		nextKid:    2,
+	}, nil
+}
+
+func (s *RotatingSigningKeySource) Current() (*rsa.PrivateKey, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current, s.currentKid
+}
+
+// Rotate loads a fresh signing key via load, retiring the outgoing key for its own overlap
+// window (still published in Keys(), no longer used to sign) independently of any key already
+// retired by an earlier call. A server's key-rotation controller calls this on whatever schedule
+// the master's signing config dictates.
+func (s *RotatingSigningKeySource) Rotate() error {
+	key, err := s.load()
+	if err != nil {
+		return fmt.Errorf("failed to load rotated OIDC signing key: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retired = append(pruneExpiredKeys(s.retired), retiredKey{
+		key:       s.current,
+		kid:       s.currentKid,
+		expiresAt: time.Now().Add(s.overlap),
+	})
+	s.current = key
+	s.currentKid = fmt.Sprintf("%d", s.nextKid)
+	s.nextKid++
+	return nil
+}
+
+func (s *RotatingSigningKeySource) Keys() JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []JSONWebKey{PublicJWK(s.currentKid, &s.current.PublicKey)}
+	for _, retired := range pruneExpiredKeys(s.retired) {
+		keys = append(keys, PublicJWK(retired.kid, &retired.key.PublicKey))
+	}
+	return JSONWebKeySet{Keys: keys}
+}
+
+func pruneExpiredKeys(retired []retiredKey) []retiredKey {
+	now := time.Now()
+	var live []retiredKey
+	for _, r := range retired {
+		if now.Before(r.expiresAt) {
+			live = append(live, r)
+		}
+	}
+	return live
+}